@@ -0,0 +1,183 @@
+package ledger
+
+import "time"
+
+// snapshotKey identifies one per-merchant, end-of-day balance checkpoint.
+type snapshotKey struct {
+	MerchantID string
+	Date       Date
+}
+
+// balanceSnapshot is a cached, cumulative balance for a merchant through the
+// end of a calendar day, paired with the highest entry ID folded into it so
+// GetMerchantBalanceAsOf only has to replay entries posted after the
+// snapshot rather than the merchant's entire history.
+type balanceSnapshot struct {
+	balance   MerchantBalance
+	throughID int
+}
+
+// dateOf returns the UTC calendar date t falls on, in the same YYYY-MM-DD
+// form settlement dates use, so its day boundaries match startOfDay's.
+func dateOf(t time.Time) Date {
+	return Date(t.UTC().Format("2006-01-02"))
+}
+
+// startOfDay returns the instant a Date begins, in UTC.
+func startOfDay(date Date) time.Time {
+	t, _ := time.Parse("2006-01-02", string(date))
+	return t
+}
+
+// GetMerchantBalanceAsOf returns merchantID's balance at a point in time,
+// computed by replaying only the entries posted since the nearest cached
+// end-of-day checkpoint strictly before at's calendar day, rather than the
+// merchant's entire history.
+func (l *Ledger) GetMerchantBalanceAsOf(merchantID string, at time.Time) MerchantBalance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.balanceAsOfLocked(merchantID, at)
+}
+
+// GetSystemBalanceAsOf returns the ledger-wide balance across all merchants
+// at a point in time, by replaying every entry posted at or before at.
+func (l *Ledger) GetSystemBalanceAsOf(at time.Time) MerchantBalance {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	bal := MerchantBalance{}
+	for _, e := range l.store.Entries() {
+		if e.CreatedAt.After(at) {
+			break
+		}
+		applyEntry(&bal, e)
+	}
+	return bal
+}
+
+// balanceAsOfLocked does the work behind GetMerchantBalanceAsOf and
+// GetDailyStatement. Callers must hold l.mu.
+func (l *Ledger) balanceAsOfLocked(merchantID string, at time.Time) MerchantBalance {
+	entries := l.store.EntriesByMerchant(merchantID)
+
+	bal := MerchantBalance{MerchantID: merchantID}
+	start := 0
+	if snap, ok := l.nearestSnapshotLocked(merchantID, at); ok {
+		bal = snap.balance
+		for i, e := range entries {
+			if e.ID == snap.throughID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	for _, e := range entries[start:] {
+		if e.CreatedAt.After(at) {
+			break
+		}
+		applyEntry(&bal, e)
+	}
+
+	return bal
+}
+
+// nearestSnapshotLocked returns the latest cached end-of-day checkpoint for
+// merchantID strictly before at's calendar day, sealing one from the
+// merchant's history on first use if none is cached yet. Callers must hold
+// l.mu.
+func (l *Ledger) nearestSnapshotLocked(merchantID string, at time.Time) (balanceSnapshot, bool) {
+	cutoff := dateOf(at)
+
+	var best balanceSnapshot
+	var bestDate Date
+	found := false
+	for key, snap := range l.snapshots {
+		if key.MerchantID != merchantID || key.Date >= cutoff {
+			continue
+		}
+		if !found || key.Date > bestDate {
+			best, bestDate, found = snap, key.Date, true
+		}
+	}
+	if found {
+		return best, true
+	}
+
+	return l.sealSnapshotBeforeLocked(merchantID, cutoff)
+}
+
+// sealSnapshotBeforeLocked folds merchantID's entries up through the latest
+// calendar day strictly before cutoff into a new checkpoint and caches it, so
+// later AsOf queries on or after that day don't replay from the beginning
+// again. Callers must hold l.mu.
+func (l *Ledger) sealSnapshotBeforeLocked(merchantID string, cutoff Date) (balanceSnapshot, bool) {
+	bal := MerchantBalance{MerchantID: merchantID}
+	var snap balanceSnapshot
+	var sealDate Date
+	sealed := false
+
+	for _, e := range l.store.EntriesByMerchant(merchantID) {
+		if dateOf(e.CreatedAt) >= cutoff {
+			break
+		}
+		applyEntry(&bal, e)
+		snap = balanceSnapshot{balance: bal, throughID: e.ID}
+		sealDate = dateOf(e.CreatedAt)
+		sealed = true
+	}
+	if !sealed {
+		return balanceSnapshot{}, false
+	}
+
+	l.snapshots[snapshotKey{MerchantID: merchantID, Date: sealDate}] = snap
+	return snap, true
+}
+
+// GetDailyStatement returns merchantID's full activity for a single calendar
+// date: the balance carried in from before that date, every journal entry
+// posted that day grouped by JournalID, the day's fee revenue and payout
+// totals, and the balance carried out.
+func (l *Ledger) GetDailyStatement(merchantID string, date Date) DailyStatement {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	opening := l.balanceAsOfLocked(merchantID, startOfDay(date).Add(-time.Nanosecond))
+
+	closing := opening
+	closing.MerchantID = merchantID
+
+	var journals [][]LedgerEntry
+	var currentJournalID int
+	var feeRevenue, payouts int64
+
+	for _, e := range l.store.EntriesByMerchant(merchantID) {
+		if dateOf(e.CreatedAt) != date {
+			continue
+		}
+
+		if len(journals) == 0 || e.JournalID != currentJournalID {
+			journals = append(journals, nil)
+			currentJournalID = e.JournalID
+		}
+		journals[len(journals)-1] = append(journals[len(journals)-1], e)
+
+		applyEntry(&closing, e)
+		if e.Purpose == PurposeFeeRevenue && e.EntryType == Credit {
+			feeRevenue += e.Amount
+		}
+		if e.Purpose == PurposePayout && e.EntryType == Credit && e.Account == AccountMerchantBank {
+			payouts += e.Amount
+		}
+	}
+
+	return DailyStatement{
+		MerchantID:     merchantID,
+		Date:           date,
+		OpeningBalance: opening,
+		Journals:       journals,
+		FeeRevenue:     feeRevenue,
+		Payouts:        payouts,
+		ClosingBalance: closing,
+	}
+}
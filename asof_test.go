@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+)
+
+// backdateTxn rewrites the CreatedAt of every entry posted for txnID to the
+// given date, so tests can simulate multi-day activity without waiting on a
+// real clock.
+func backdateTxn(t *testing.T, l *Ledger, txnID string, date Date) {
+	t.Helper()
+	ms, ok := l.store.(*MemoryStore)
+	if !ok {
+		t.Fatalf("backdateTxn requires a MemoryStore-backed Ledger")
+	}
+
+	at := startOfDay(date).Add(12 * time.Hour)
+	for i := range ms.entries {
+		if ms.entries[i].TransactionID == txnID {
+			ms.entries[i].CreatedAt = at
+		}
+	}
+	for merchantID, entries := range ms.merchantEntries {
+		for i := range entries {
+			if entries[i].TransactionID == txnID {
+				ms.merchantEntries[merchantID][i].CreatedAt = at
+			}
+		}
+	}
+}
+
+// TestGetMerchantBalanceAsOfReplaysOnlyPriorEntries verifies that an AsOf
+// query only folds in entries posted at or before the requested time, not
+// ones that arrive later.
+func TestGetMerchantBalanceAsOfReplaysOnlyPriorEntries(t *testing.T) {
+	l := NewLedger(nil)
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization txn-1: %v", err)
+	}
+	backdateTxn(t, l, "txn-1", "2026-01-01")
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-2", MerchantID: "m1", CardNumber: "4242", Amount: 500, ProcessorRefID: "ref-2",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization txn-2: %v", err)
+	}
+	backdateTxn(t, l, "txn-2", "2026-01-02")
+
+	day0 := l.GetMerchantBalanceAsOf("m1", startOfDay("2026-01-01").Add(23*time.Hour))
+	if day0.Pending != 1000 {
+		t.Errorf("balance as of 2026-01-01: got Pending %d, want 1000", day0.Pending)
+	}
+
+	day1 := l.GetMerchantBalanceAsOf("m1", startOfDay("2026-01-02").Add(23*time.Hour))
+	if day1.Pending != 1500 {
+		t.Errorf("balance as of 2026-01-02: got Pending %d, want 1500", day1.Pending)
+	}
+
+	before := l.GetMerchantBalanceAsOf("m1", startOfDay("2025-12-31"))
+	if before.Pending != 0 {
+		t.Errorf("balance before any activity: got Pending %d, want 0", before.Pending)
+	}
+}
+
+// TestGetSystemBalanceAsOfExcludesLaterEntries mirrors the merchant-scoped
+// test but for the system-wide balance.
+func TestGetSystemBalanceAsOfExcludesLaterEntries(t *testing.T) {
+	l := NewLedger(nil)
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization: %v", err)
+	}
+	backdateTxn(t, l, "txn-1", "2026-01-01")
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-2", MerchantID: "m2", CardNumber: "4242", Amount: 2000, ProcessorRefID: "ref-2",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization: %v", err)
+	}
+	backdateTxn(t, l, "txn-2", "2026-01-03")
+
+	mid := l.GetSystemBalanceAsOf(startOfDay("2026-01-02"))
+	if mid.Pending != 1000 {
+		t.Errorf("system balance as of 2026-01-02: got Pending %d, want 1000", mid.Pending)
+	}
+}
+
+// TestGetDailyStatementGroupsByJournalAndDay verifies that GetDailyStatement
+// carries forward the correct opening balance, includes only the requested
+// day's entries grouped by JournalID, and ends at the correct closing
+// balance.
+func TestGetDailyStatementGroupsByJournalAndDay(t *testing.T) {
+	l := NewLedger(nil)
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization txn-1: %v", err)
+	}
+	backdateTxn(t, l, "txn-1", "2026-01-01")
+
+	if err := l.RecordAuthorization(Transaction{
+		TransactionID: "txn-2", MerchantID: "m1", CardNumber: "4242", Amount: 500, ProcessorRefID: "ref-2",
+	}); err != nil {
+		t.Fatalf("RecordAuthorization txn-2: %v", err)
+	}
+	backdateTxn(t, l, "txn-2", "2026-01-02")
+
+	stmt := l.GetDailyStatement("m1", "2026-01-02")
+
+	if stmt.OpeningBalance.Pending != 1000 {
+		t.Errorf("opening balance: got Pending %d, want 1000", stmt.OpeningBalance.Pending)
+	}
+	if stmt.ClosingBalance.Pending != 1500 {
+		t.Errorf("closing balance: got Pending %d, want 1500", stmt.ClosingBalance.Pending)
+	}
+	if len(stmt.Journals) != 1 {
+		t.Fatalf("got %d journals for the day, want 1", len(stmt.Journals))
+	}
+	if len(stmt.Journals[0]) != 2 {
+		t.Fatalf("got %d entries in the day's journal, want 2 (debit+credit)", len(stmt.Journals[0]))
+	}
+	for _, e := range stmt.Journals[0] {
+		if e.TransactionID != "txn-2" {
+			t.Errorf("entry from wrong day leaked into statement: %+v", e)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ledger
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -11,33 +12,43 @@ import (
 type PayoutFunc func(merchantID string, amount int64, reference string) error
 
 // Ledger is the core ledger that tracks funds through double-entry bookkeeping.
+// mu guards every read and mutation of transaction state so that late-arriving
+// settlement rows, out-of-order bank deposits, and concurrent payout batches
+// can't race each other into double-moving funds.
 type Ledger struct {
-	transactions     map[string]*Transaction // keyed by transaction_id
-	refIndex         map[string]string       // processor_ref_id -> transaction_id
-	entries          []LedgerEntry
-	nextEntryID      int
-	processedFiles   map[string]bool   // settlement file IDs already processed
-	settlementTotals map[string]int64  // settlement_date -> expected total
-	settlementDates  map[string]string // settlement_date -> file tracking
-	payoutFunc       PayoutFunc
+	mu         sync.RWMutex
+	store      Store
+	payoutFunc PayoutFunc
+
+	// snapshots caches per-merchant, end-of-day cumulative balances so
+	// GetMerchantBalanceAsOf and GetDailyStatement don't have to replay a
+	// merchant's entire history on every call. See asof.go.
+	snapshots map[snapshotKey]balanceSnapshot
 }
 
-// NewLedger creates a new in-memory ledger.
+// NewLedger creates a new Ledger backed by an in-memory Store. This is the
+// right choice for tests and single-process deployments.
 func NewLedger(payoutFunc PayoutFunc) *Ledger {
+	return NewLedgerWithStore(payoutFunc, NewMemoryStore())
+}
+
+// NewLedgerWithStore creates a new Ledger backed by the given Store, so it
+// can run across process restarts (and, for Store implementations that
+// enforce idempotency in the database, across multiple processes).
+func NewLedgerWithStore(payoutFunc PayoutFunc, store Store) *Ledger {
 	return &Ledger{
-		transactions:     make(map[string]*Transaction),
-		refIndex:         make(map[string]string),
-		entries:          make([]LedgerEntry, 0),
-		processedFiles:   make(map[string]bool),
-		settlementTotals: make(map[string]int64),
-		settlementDates:  make(map[string]string),
-		payoutFunc:       payoutFunc,
+		store:      store,
+		payoutFunc: payoutFunc,
+		snapshots:  make(map[snapshotKey]balanceSnapshot),
 	}
 }
 
 // RecordAuthorization records an approved card authorization in the ledger.
 // It creates a journal entry: debit CardProcessor, credit Pending.
 func (l *Ledger) RecordAuthorization(txn Transaction) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if txn.TransactionID == "" {
 		return errors.New("transaction_id is required")
 	}
@@ -52,151 +63,482 @@ func (l *Ledger) RecordAuthorization(txn Transaction) error {
 	}
 
 	// Idempotency: skip if already recorded.
-	if _, exists := l.transactions[txn.TransactionID]; exists {
+	if _, err := l.store.GetTransaction(txn.TransactionID); err == nil {
 		return fmt.Errorf("transaction %s already exists", txn.TransactionID)
 	}
 
 	txn.Status = StatusPending
 	txn.CreatedAt = time.Now()
-	l.transactions[txn.TransactionID] = &txn
-	l.refIndex[txn.ProcessorRefID] = txn.TransactionID
+	txn.FeeReserved = estimateFee(txn.Amount, txn.FeeBps, txn.FixedFee)
+	if err := l.store.SaveTransaction(&txn); err != nil {
+		return fmt.Errorf("saving transaction %s: %w", txn.TransactionID, err)
+	}
 
-	l.addEntry(txn.TransactionID, txn.MerchantID, AccountCardProcessor, AccountPending, txn.Amount, "authorization")
+	l.addEntry(txn.TransactionID, txn.MerchantID, AccountCardProcessor, AccountPending, txn.Amount, PurposeAuthorization)
+	if txn.FeeReserved > 0 {
+		l.addEntry(txn.TransactionID, txn.MerchantID, AccountPending, AccountFeeReserve, txn.FeeReserved, PurposeFeeReserve)
+	}
 
 	return nil
 }
 
+// estimateFee computes the fee reserved at authorization time: a fixed
+// component plus a basis-points cut of the authorized amount.
+func estimateFee(amount, feeBps, fixedFee int64) int64 {
+	return fixedFee + (amount*feeBps)/10000
+}
+
+// RecordRefund reverses amount of a transaction's funds back out to the card
+// processor, debiting whatever account the funds currently sit in (Pending,
+// Available, or Funded). If the funds have already been paid out (Funded), it
+// instead posts a clawback obligation that the next ExecutePayoutBatch will
+// net against, since the cash itself already left the building.
+//
+// amount may be less than the transaction's full value, but a refund of any
+// size — partial or full — moves the transaction straight to the terminal
+// StatusRefunded: there's no partially-refunded status to return to, so it
+// can't be settled further or refunded again. A processor that splits a
+// refund across multiple calls against the same transaction isn't supported;
+// the first call wins and later ones fail with "cannot be reversed".
+func (l *Ledger) RecordRefund(txnID string, amount int64, ref string) error {
+	return l.recordReversal(txnID, amount, ref, PurposeRefund, StatusRefunded)
+}
+
+// RecordChargeback reverses a transaction the same way RecordRefund does,
+// including its partial-amount semantics, but tags the reversal entries with
+// the card network's dispute reason code so chargebacks can be distinguished
+// from voluntary refunds in audit exports.
+func (l *Ledger) RecordChargeback(txnID string, amount int64, reasonCode string) error {
+	return l.recordReversal(txnID, amount, reasonCode, PurposeChargeback, StatusChargedBack)
+}
+
+// recordReversal implements the shared logic behind RecordRefund and
+// RecordChargeback: both move funds back to the card processor and leave the
+// transaction in a terminal reversed status, differing only in EntryPurpose
+// and in which terminal status they set.
+// amount is capped at what the transaction actually has sitting in its
+// current account — Amount while Pending (authorized but not yet settled),
+// or NetSettled once Available or Funded (settlement already deducted the
+// processor's fee) — so an over-refund can't drive that account negative.
+func (l *Ledger) recordReversal(txnID string, amount int64, ref string, purpose EntryPurpose, terminal TransactionStatus) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+
+	txn, err := l.store.GetTransaction(txnID)
+	if err != nil {
+		return fmt.Errorf("transaction %s not found", txnID)
+	}
+
+	origin := l.originalJournalID(txnID)
+	from := txn.Status
+
+	reversible := txn.Amount
+	if from == StatusAvailable || from == StatusFunded {
+		reversible = txn.NetSettled
+	}
+	if amount > reversible {
+		return fmt.Errorf("transaction %s: amount %d exceeds %d available to reverse", txnID, amount, reversible)
+	}
+
+	if from == StatusFunded {
+		return l.transition(txn, from, terminal, func() {
+			l.addReversalEntry(txnID, txn.MerchantID, AccountClawback, AccountCardProcessor, amount, purpose, origin, ref)
+		})
+	}
+
+	debitAcct, ok := accountForStatus(from)
+	if !ok {
+		return fmt.Errorf("transaction %s cannot be reversed from status %s", txnID, from)
+	}
+
+	return l.transition(txn, from, terminal, func() {
+		l.addReversalEntry(txnID, txn.MerchantID, debitAcct, AccountCardProcessor, amount, purpose, origin, ref)
+	})
+}
+
+// transition atomically moves txn from one status to another: it verifies
+// txn is still in the expected from status, runs post to append the
+// journal entries for the move, then sets and saves the new status. Every
+// method that mutates a transaction's lifecycle goes through this so that
+// concurrent callers can't observe a transaction mid-move or double-post
+// entries against it. Callers must hold l.mu.
+func (l *Ledger) transition(txn *Transaction, from, to TransactionStatus, post func()) error {
+	if txn.Status != from {
+		return fmt.Errorf("transaction %s is %s, not %s", txn.TransactionID, txn.Status, from)
+	}
+	post()
+	txn.Status = to
+	return l.store.SaveTransaction(txn)
+}
+
+// accountForStatus maps a transaction's current status to the account its
+// funds sit in, for statuses that are eligible to be reversed.
+func accountForStatus(status TransactionStatus) (Account, bool) {
+	switch status {
+	case StatusPending:
+		return AccountPending, true
+	case StatusAvailable:
+		return AccountAvailable, true
+	case StatusFunded:
+		return AccountFunded, true
+	default:
+		return "", false
+	}
+}
+
+// originalJournalID finds the JournalID of a transaction's original
+// authorization entry, so reversal entries can reference it for audit trails.
+func (l *Ledger) originalJournalID(txnID string) int {
+	for _, e := range l.store.Entries() {
+		if e.TransactionID == txnID && e.Purpose == PurposeAuthorization {
+			return e.JournalID
+		}
+	}
+	return 0
+}
+
 // ProcessSettlementFile processes a daily settlement file from the card processor.
-// It moves matched transactions from Pending to Settling and flags unknown rows.
+// A ProcessorRefID may appear across several rows, possibly spread over several
+// files, when the processor settles a transaction in fragments (partial
+// captures): each row reverses its proportional share of the fee reserved at
+// authorization time, posts the true fee to FeeRevenue, and moves its
+// GrossAmount, net of fee, from Pending to Settling. A transaction only leaves
+// StatusPartiallySettled once its rows' GrossAmounts sum to its full Amount.
+// Unknown rows are flagged rather than rejecting the whole file.
 func (l *Ledger) ProcessSettlementFile(file SettlementFile) (*SettlementFileResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if file.FileID == "" {
 		return nil, errors.New("file_id is required")
 	}
 
 	// Idempotency: skip if already processed.
-	if l.processedFiles[file.FileID] {
+	isNew, err := l.store.MarkFileProcessed(file.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("marking file %s processed: %w", file.FileID, err)
+	}
+	if !isNew {
 		return &SettlementFileResult{}, nil
 	}
 
 	result := &SettlementFileResult{}
-	var totalAmount int64
+	var netTotal int64
 
 	for _, row := range file.Rows {
-		totalAmount += row.Amount
-
-		txnID, found := l.refIndex[row.ProcessorRefID]
+		txnID, found := l.store.LookupByProcessorRef(row.ProcessorRefID)
 		if !found {
 			result.Unmatched++
 			result.UnmatchedRows = append(result.UnmatchedRows, row)
 			continue
 		}
 
-		txn := l.transactions[txnID]
+		txn, err := l.store.GetTransaction(txnID)
+		if err != nil {
+			result.Unmatched++
+			result.UnmatchedRows = append(result.UnmatchedRows, row)
+			continue
+		}
 
-		// Skip if already settled (shouldn't create duplicate entries).
-		if txn.Status != StatusPending {
+		// Skip if already fully settled, refunded, or charged back (shouldn't
+		// create duplicate or conflicting entries). Pending and
+		// PartiallySettled transactions can still take more settlement rows.
+		switch txn.Status {
+		case StatusRefunded:
+			result.Refunds++
+			continue
+		case StatusChargedBack:
+			result.Chargebacks++
+			continue
+		case StatusPending, StatusPartiallySettled:
+			// proceed to settle below
+		default:
 			result.AlreadySettled++
 			continue
 		}
 
-		txn.Status = StatusSettling
+		remaining := txn.Amount - txn.SettledAmount
+		if row.GrossAmount > remaining {
+			// This row's GrossAmount would settle more than the transaction
+			// was ever authorized for; reject it rather than overshoot.
+			result.Unmatched++
+			result.UnmatchedRows = append(result.UnmatchedRows, row)
+			continue
+		}
+
+		// Reverse this row's share of the fee reserve, proportional to how
+		// much of the outstanding amount it covers; a row that covers all of
+		// what's left reverses all of what's left, so rounding never leaves
+		// a dangling reserve behind.
+		reserveToReverse := int64(0)
+		if txn.FeeReserved > 0 && remaining > 0 {
+			reserveToReverse = txn.FeeReserved * row.GrossAmount / remaining
+		}
+
+		net := row.GrossAmount - row.FeeAmount
+		newSettled := txn.SettledAmount + row.GrossAmount
+		to := StatusPartiallySettled
+		if newSettled == txn.Amount {
+			to = StatusSettling
+		}
+
+		from := txn.Status
 		txn.SettlementDate = file.Date
-		l.addEntry(txn.TransactionID, txn.MerchantID, AccountPending, AccountSettling, row.Amount, "settlement")
-		result.Matched++
+		err = l.transition(txn, from, to, func() {
+			if reserveToReverse > 0 {
+				l.addEntry(txn.TransactionID, txn.MerchantID, AccountFeeReserve, AccountPending, reserveToReverse, PurposeFeeReserveReversal)
+				txn.FeeReserved -= reserveToReverse
+			}
+			if row.FeeAmount > 0 {
+				l.addEntry(txn.TransactionID, txn.MerchantID, AccountPending, AccountFeeRevenue, row.FeeAmount, PurposeFeeRevenue)
+			}
+			l.addEntry(txn.TransactionID, txn.MerchantID, AccountPending, AccountSettling, net, PurposeSettlement)
+			txn.SettledAmount = newSettled
+			txn.NetSettled += net
+		})
+		if err != nil {
+			// Lost the race with another mutation between the switch above and
+			// here (e.g. a concurrent reconciliation); treat it the same as an
+			// already-settled row rather than failing the whole file.
+			result.AlreadySettled++
+			continue
+		}
+		netTotal += net
+		if to == StatusPartiallySettled {
+			result.PartiallySettled++
+		} else {
+			result.Matched++
+		}
 	}
 
-	l.processedFiles[file.FileID] = true
-	l.settlementTotals[file.Date] = totalAmount
+	l.store.AddSettlementTotal(file.Date, netTotal)
 
 	return result, nil
 }
 
-// ReconcileBankDeposit confirms a bank deposit matches the expected settlement total
-// and moves all settling transactions for that date to Available.
-func (l *Ledger) ReconcileBankDeposit(amount int64, settlementDate string) error {
-	expected, exists := l.settlementTotals[settlementDate]
+// ReconcileBankDeposit records a bank deposit against a settlement date's
+// expected total and, once deposits for that date cumulatively cover it,
+// moves every settling transaction for that date to Available. Processors pay
+// out in more than one deposit per settlement date, so a deposit that only
+// partially covers what's expected is accepted and tracked rather than
+// rejected; only a deposit that would push the cumulative total past what's
+// expected is treated as a mismatch.
+func (l *Ledger) ReconcileBankDeposit(deposit BankDeposit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expected, exists := l.store.SettlementTotal(deposit.SettlementDate)
 	if !exists {
-		return fmt.Errorf("no settlement found for date %s", settlementDate)
+		return fmt.Errorf("no settlement found for date %s", deposit.SettlementDate)
 	}
 
-	if amount != expected {
-		return fmt.Errorf("deposit mismatch for %s: expected %d, got %d", settlementDate, expected, amount)
+	receivedSoFar, _ := l.store.SettlementReceived(deposit.SettlementDate)
+	received := receivedSoFar + deposit.Amount
+	if received > expected {
+		return fmt.Errorf("deposit mismatch for %s: expected at most %d more, got %d (already received %d of %d)",
+			deposit.SettlementDate, expected-receivedSoFar, deposit.Amount, receivedSoFar, expected)
 	}
+	l.store.AddSettlementReceived(deposit.SettlementDate, deposit.Amount)
 
-	// Move all settling transactions for this date to available.
-	for _, txn := range l.transactions {
-		if txn.Status == StatusSettling && txn.SettlementDate == settlementDate {
-			txn.Status = StatusAvailable
-			l.addEntry(txn.TransactionID, txn.MerchantID, AccountSettling, AccountAvailable, txn.Amount, "bank_reconciliation")
+	if received < expected {
+		// Partial coverage so far; wait for more deposits before releasing
+		// the date's settling transactions.
+		return nil
+	}
+
+	// Fully covered: move all settling transactions for this date to available.
+	for txn := range l.store.IterateByStatusAndDate(StatusSettling, deposit.SettlementDate) {
+		if err := l.transition(txn, StatusSettling, StatusAvailable, func() {
+			l.addEntry(txn.TransactionID, txn.MerchantID, AccountSettling, AccountAvailable, txn.NetSettled, PurposeBankReconciliation)
+		}); err != nil {
+			return fmt.Errorf("saving transaction %s: %w", txn.TransactionID, err)
 		}
 	}
 
 	return nil
 }
 
-// ExecutePayoutBatch calculates available balances per merchant, issues payouts,
-// and moves successful payouts to Funded.
+// ExecutePayoutBatch calculates available balances per merchant, issues
+// payouts, and moves successful payouts through Funded and out to
+// AccountMerchantBank.
+//
+// payoutFunc is an external bank call that may be slow or block, so it's
+// never invoked while holding l.mu: every Available transaction this batch
+// intends to pay is first claimed into the interim StatusFunding under lock,
+// which is what stops a concurrent ExecutePayoutBatch from also picking up
+// the same funds while this one's payoutFunc call is in flight, not the lock
+// itself. The lock is re-acquired only to record the outcome: Funding moves
+// to Funded on success, or back to Available (for the next batch to retry)
+// on failure.
 func (l *Ledger) ExecutePayoutBatch() []PayoutResult {
-	// Aggregate available balances per merchant.
+	l.mu.Lock()
+
+	// Aggregate available balances per merchant. NetSettled, not Amount, is
+	// what actually sits in AccountAvailable: settlement already deducted
+	// the processor's fee, so paying out Amount would hand the merchant
+	// back the fee revenue the PayFac is owed.
 	available := make(map[string]int64)
-	for _, txn := range l.transactions {
+	for _, txn := range l.store.Transactions() {
 		if txn.Status == StatusAvailable {
-			available[txn.MerchantID] += txn.Amount
+			available[txn.MerchantID] += txn.NetSettled
 		}
 	}
 
-	var results []PayoutResult
+	type payoutPlan struct {
+		offset  int64
+		payable int64
+	}
+	plans := make(map[string]payoutPlan, len(available))
 
 	for merchantID, amount := range available {
 		if amount <= 0 {
 			continue
 		}
 
+		// Net any outstanding clawback (owed back after a refund/chargeback on
+		// money already marked Funded) against this payout: the merchant is
+		// only actually paid what's left once the debt is covered.
+		offset := int64(0)
+		if clawback := l.merchantBalanceLocked(merchantID).Clawback; clawback < 0 {
+			offset = -clawback
+			if offset > amount {
+				offset = amount
+			}
+		}
+		plans[merchantID] = payoutPlan{offset: offset, payable: amount - offset}
+
+		l.claimAvailableForFunding(merchantID)
+	}
+
+	l.mu.Unlock()
+
+	var results []PayoutResult
+
+	for merchantID, plan := range plans {
+		if plan.payable <= 0 {
+			// The whole claimed balance covers the clawback; nothing to
+			// disburse, but the claimed transactions still settle.
+			l.mu.Lock()
+			l.settleFundingToFunded(merchantID)
+			l.addEntry("", merchantID, AccountFunded, AccountClawback, plan.offset, PurposeClawbackOffset)
+			l.mu.Unlock()
+			continue
+		}
+
 		reference := fmt.Sprintf("payout-%s-%d", merchantID, time.Now().UnixNano())
-		err := l.payoutFunc(merchantID, amount, reference)
-		result := PayoutResult{
+		err := l.payoutFunc(merchantID, plan.payable, reference)
+		results = append(results, PayoutResult{
 			MerchantID: merchantID,
-			Amount:     amount,
+			Amount:     plan.payable,
 			Success:    err == nil,
 			Error:      err,
-		}
-		results = append(results, result)
+		})
 
+		l.mu.Lock()
 		if err == nil {
-			// Move all available transactions for this merchant to posted.
-			for _, txn := range l.transactions {
-				if txn.MerchantID == merchantID && txn.Status == StatusAvailable {
-					txn.Status = StatusFunded
-					l.addEntry(txn.TransactionID, txn.MerchantID, AccountAvailable, AccountFunded, txn.Amount, "payout")
-				}
+			l.settleFundingToFunded(merchantID)
+			if plan.offset > 0 {
+				l.addEntry("", merchantID, AccountFunded, AccountClawback, plan.offset, PurposeClawbackOffset)
 			}
+		} else {
+			l.releaseFundingToAvailable(merchantID)
 		}
+		l.mu.Unlock()
 	}
 
 	return results
 }
 
-// GetMerchantBalance returns the current balance in each state for a merchant.
+// claimAvailableForFunding moves every Available transaction for a merchant
+// to the interim StatusFunding, reserving it for this batch's in-flight
+// payoutFunc call without yet moving any ledger funds. Callers must hold l.mu.
+func (l *Ledger) claimAvailableForFunding(merchantID string) {
+	for _, txn := range l.store.Transactions() {
+		if txn.MerchantID == merchantID && txn.Status == StatusAvailable {
+			_ = l.transition(txn, StatusAvailable, StatusFunding, func() {})
+		}
+	}
+}
+
+// settleFundingToFunded moves every Funding transaction for a merchant to
+// Funded. Since payoutFunc has by now actually paid the money out, it posts
+// two entries per transaction, for its NetSettled amount (which is what
+// actually sits in AccountAvailable): Available->Funded, then immediately
+// Funded->AccountMerchantBank so the disbursed cash doesn't stay parked in
+// Funded forever. Per-transaction history stays intact either way. Callers
+// must hold l.mu.
+func (l *Ledger) settleFundingToFunded(merchantID string) {
+	for _, txn := range l.store.Transactions() {
+		if txn.MerchantID == merchantID && txn.Status == StatusFunding {
+			_ = l.transition(txn, StatusFunding, StatusFunded, func() {
+				l.addEntry(txn.TransactionID, txn.MerchantID, AccountAvailable, AccountFunded, txn.NetSettled, PurposePayout)
+				l.addEntry(txn.TransactionID, txn.MerchantID, AccountFunded, AccountMerchantBank, txn.NetSettled, PurposePayout)
+			})
+		}
+	}
+}
+
+// releaseFundingToAvailable reverts every Funding transaction for a merchant
+// back to Available after a failed payoutFunc call, so the next batch
+// retries it. Callers must hold l.mu.
+func (l *Ledger) releaseFundingToAvailable(merchantID string) {
+	for _, txn := range l.store.Transactions() {
+		if txn.MerchantID == merchantID && txn.Status == StatusFunding {
+			_ = l.transition(txn, StatusFunding, StatusAvailable, func() {})
+		}
+	}
+}
+
+// GetMerchantBalance returns the current balance in each state for a merchant,
+// net of reserved and recognized fees.
 // Balances are computed from individual debit/credit entries per account.
 func (l *Ledger) GetMerchantBalance(merchantID string) MerchantBalance {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.merchantBalanceLocked(merchantID)
+}
+
+// merchantBalanceLocked is GetMerchantBalance without its own locking, for use
+// by methods (like ExecutePayoutBatch) that already hold l.mu.
+func (l *Ledger) merchantBalanceLocked(merchantID string) MerchantBalance {
 	bal := MerchantBalance{MerchantID: merchantID}
 
-	for _, e := range l.entries {
-		if e.MerchantID != merchantID {
-			continue
-		}
+	for _, e := range l.store.EntriesByMerchant(merchantID) {
+		applyEntry(&bal, e)
+	}
 
-		switch e.EntryType {
-		case Credit:
-			addToAccount(&bal, e.Account, e.Amount)
-		case Debit:
-			addToAccount(&bal, e.Account, -e.Amount)
-		}
+	return bal
+}
+
+// GetSystemBalance returns the ledger-wide balance in each account state,
+// across all merchants.
+func (l *Ledger) GetSystemBalance() MerchantBalance {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	bal := MerchantBalance{}
+
+	for _, e := range l.store.Entries() {
+		applyEntry(&bal, e)
 	}
 
 	return bal
 }
 
+// applyEntry folds a single ledger entry into a running balance.
+func applyEntry(bal *MerchantBalance, e LedgerEntry) {
+	switch e.EntryType {
+	case Credit:
+		addToAccount(bal, e.Account, e.Amount)
+	case Debit:
+		addToAccount(bal, e.Account, -e.Amount)
+	}
+}
+
 // addToAccount adds an amount to the appropriate field in MerchantBalance.
 func addToAccount(bal *MerchantBalance, acct Account, amount int64) {
 	switch acct {
@@ -208,39 +550,85 @@ func addToAccount(bal *MerchantBalance, acct Account, amount int64) {
 		bal.Available += amount
 	case AccountFunded:
 		bal.Funded += amount
+	case AccountFeeReserve:
+		bal.FeeReserve += amount
+	case AccountFeeRevenue:
+		bal.FeeRevenue += amount
+	case AccountClawback:
+		bal.Clawback += amount
 	}
 }
 
 // addEntry creates a proper double-entry journal entry: two rows (debit + credit)
 // linked by the same journal ID.
-func (l *Ledger) addEntry(txnID, merchantID string, debitAcct, creditAcct Account, amount int64, ref string) {
-	l.nextEntryID++
-	journalID := l.nextEntryID
+func (l *Ledger) addEntry(txnID, merchantID string, debitAcct, creditAcct Account, amount int64, purpose EntryPurpose) {
+	journalID := l.store.NextJournalID()
 	now := time.Now()
 
-	// Debit entry (money leaving this account).
-	l.entries = append(l.entries, LedgerEntry{
-		ID:            journalID*2 - 1,
-		JournalID:     journalID,
-		TransactionID: txnID,
-		MerchantID:    merchantID,
-		Account:       debitAcct,
-		EntryType:     Debit,
-		Amount:        amount,
-		CreatedAt:     now,
-		Reference:     ref,
+	// Debit entry (money leaving this account) and credit entry (money
+	// entering this account), appended together so they can never be split.
+	_ = l.store.AppendJournal([]LedgerEntry{
+		{
+			ID:            journalID*2 - 1,
+			JournalID:     journalID,
+			TransactionID: txnID,
+			MerchantID:    merchantID,
+			Account:       debitAcct,
+			EntryType:     Debit,
+			Amount:        amount,
+			CreatedAt:     now,
+			Purpose:       purpose,
+			Reference:     string(purpose),
+		},
+		{
+			ID:            journalID * 2,
+			JournalID:     journalID,
+			TransactionID: txnID,
+			MerchantID:    merchantID,
+			Account:       creditAcct,
+			EntryType:     Credit,
+			Amount:        amount,
+			CreatedAt:     now,
+			Purpose:       purpose,
+			Reference:     string(purpose),
+		},
 	})
+}
+
+// addReversalEntry posts a two-sided entry like addEntry, but tags both rows
+// with ReversesJournalID so audit tooling can trace a correction back to the
+// journal entry it corrects, and accepts a caller-supplied Reference (e.g. a
+// refund note or chargeback reason code) instead of deriving it from purpose.
+func (l *Ledger) addReversalEntry(txnID, merchantID string, debitAcct, creditAcct Account, amount int64, purpose EntryPurpose, reversesJournalID int, reference string) {
+	journalID := l.store.NextJournalID()
+	now := time.Now()
 
-	// Credit entry (money entering this account).
-	l.entries = append(l.entries, LedgerEntry{
-		ID:            journalID * 2,
-		JournalID:     journalID,
-		TransactionID: txnID,
-		MerchantID:    merchantID,
-		Account:       creditAcct,
-		EntryType:     Credit,
-		Amount:        amount,
-		CreatedAt:     now,
-		Reference:     ref,
+	_ = l.store.AppendJournal([]LedgerEntry{
+		{
+			ID:                journalID*2 - 1,
+			JournalID:         journalID,
+			TransactionID:     txnID,
+			MerchantID:        merchantID,
+			Account:           debitAcct,
+			EntryType:         Debit,
+			Amount:            amount,
+			CreatedAt:         now,
+			Purpose:           purpose,
+			Reference:         reference,
+			ReversesJournalID: reversesJournalID,
+		},
+		{
+			ID:                journalID * 2,
+			JournalID:         journalID,
+			TransactionID:     txnID,
+			MerchantID:        merchantID,
+			Account:           creditAcct,
+			EntryType:         Credit,
+			Amount:            amount,
+			CreatedAt:         now,
+			Purpose:           purpose,
+			Reference:         reference,
+			ReversesJournalID: reversesJournalID,
+		},
 	})
 }
@@ -2,7 +2,10 @@ package ledger
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 // TestHappyPath runs through the full lifecycle:
@@ -41,9 +44,9 @@ func TestHappyPath(t *testing.T) {
 		FileID: "file-1",
 		Date:   "2026-02-10",
 		Rows: []SettlementRow{
-			{ProcessorRefID: "ref-1", MerchantID: "merchant-A", Amount: 1000},
-			{ProcessorRefID: "ref-2", MerchantID: "merchant-A", Amount: 2000},
-			{ProcessorRefID: "ref-3", MerchantID: "merchant-B", Amount: 3000},
+			{ProcessorRefID: "ref-1", MerchantID: "merchant-A", GrossAmount: 1000},
+			{ProcessorRefID: "ref-2", MerchantID: "merchant-A", GrossAmount: 2000},
+			{ProcessorRefID: "ref-3", MerchantID: "merchant-B", GrossAmount: 3000},
 		},
 	})
 	if err != nil {
@@ -107,6 +110,63 @@ func TestHappyPath(t *testing.T) {
 	}
 }
 
+// TestFeeBearingLifecycleNetsFeeOutOfAvailableAndFunded verifies that a
+// transaction with a non-zero processor fee moves only the net (gross minus
+// fee) amount through Settling, Available, and Funded, rather than the full
+// authorized Amount — the fee itself stays behind as recognized FeeRevenue.
+func TestFeeBearingLifecycleNetsFeeOutOfAvailableAndFunded(t *testing.T) {
+	payoutFunc := func(merchantID string, amount int64, reference string) error {
+		return nil
+	}
+	l := NewLedger(payoutFunc)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "merchant-A", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+
+	_, err := l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "merchant-A", GrossAmount: 1000, FeeAmount: 30}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessSettlementFile: %v", err)
+	}
+
+	bal := l.GetMerchantBalance("merchant-A")
+	if bal.Settling != 970 {
+		t.Errorf("merchant-A settling (net of fee): got %d, want 970", bal.Settling)
+	}
+	if bal.FeeRevenue != 30 {
+		t.Errorf("merchant-A fee revenue: got %d, want 30", bal.FeeRevenue)
+	}
+
+	if err := l.ReconcileBankDeposit(BankDeposit{Amount: 970, SettlementDate: "2026-02-10"}); err != nil {
+		t.Fatalf("ReconcileBankDeposit: %v", err)
+	}
+
+	bal = l.GetMerchantBalance("merchant-A")
+	if bal.Available != 970 {
+		t.Errorf("merchant-A available (net of fee): got %d, want 970", bal.Available)
+	}
+
+	payouts := l.ExecutePayoutBatch()
+	if len(payouts) != 1 {
+		t.Fatalf("payouts: got %d, want 1", len(payouts))
+	}
+	if payouts[0].Amount != 970 {
+		t.Errorf("payout amount (net of fee): got %d, want 970", payouts[0].Amount)
+	}
+
+	// Funded is a waypoint, not a resting state: once payoutFunc succeeds the
+	// net amount disburses straight through to the merchant's bank, so the
+	// balance that persists is Funded back at zero, same as TestHappyPath.
+	bal = l.GetMerchantBalance("merchant-A")
+	if bal.Funded != 0 {
+		t.Errorf("merchant-A funded after disbursement: got %d, want 0", bal.Funded)
+	}
+}
+
 // TestUnknownSettlementRow verifies that settlement rows without a matching
 // transaction are flagged but don't block processing of valid rows.
 func TestUnknownSettlementRow(t *testing.T) {
@@ -120,8 +180,8 @@ func TestUnknownSettlementRow(t *testing.T) {
 		FileID: "file-1",
 		Date:   "2026-02-10",
 		Rows: []SettlementRow{
-			{ProcessorRefID: "ref-1", MerchantID: "m1", Amount: 500},
-			{ProcessorRefID: "ref-unknown", MerchantID: "m2", Amount: 999},
+			{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 500},
+			{ProcessorRefID: "ref-unknown", MerchantID: "m2", GrossAmount: 999},
 		},
 	})
 	if err != nil {
@@ -145,8 +205,9 @@ func TestUnknownSettlementRow(t *testing.T) {
 	}
 }
 
-// TestDepositMismatch verifies that a bank deposit that doesn't match the
-// expected settlement total is rejected and no state changes occur.
+// TestDepositMismatch verifies that a bank deposit that would push the
+// cumulative total received for a settlement date past what's expected is
+// rejected and no state changes occur.
 func TestDepositMismatch(t *testing.T) {
 	l := NewLedger(nil)
 
@@ -157,11 +218,11 @@ func TestDepositMismatch(t *testing.T) {
 	_, _ = l.ProcessSettlementFile(SettlementFile{
 		FileID: "file-1",
 		Date:   "2026-02-10",
-		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", Amount: 1000}},
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
 	})
 
-	// Wrong amount.
-	err := l.ReconcileBankDeposit(BankDeposit{Amount: 999, SettlementDate: "2026-02-10"})
+	// Deposit exceeds the expected total for the date.
+	err := l.ReconcileBankDeposit(BankDeposit{Amount: 1500, SettlementDate: "2026-02-10"})
 	if err == nil {
 		t.Fatal("expected error for mismatched deposit, got nil")
 	}
@@ -176,6 +237,97 @@ func TestDepositMismatch(t *testing.T) {
 	}
 }
 
+// TestPartialDepositHoldsFundsUntilFullyCovered verifies that a deposit
+// covering only part of a settlement date's expected total is accepted and
+// tracked, but doesn't release funds until later deposits complete it.
+func TestPartialDepositHoldsFundsUntilFullyCovered(t *testing.T) {
+	l := NewLedger(nil)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+
+	// First deposit only covers part of the expected total.
+	if err := l.ReconcileBankDeposit(BankDeposit{Amount: 400, SettlementDate: "2026-02-10"}); err != nil {
+		t.Fatalf("ReconcileBankDeposit (partial): %v", err)
+	}
+	bal := l.GetMerchantBalance("m1")
+	if bal.Available != 0 {
+		t.Errorf("m1 available after partial deposit: got %d, want 0", bal.Available)
+	}
+
+	// Second deposit completes the expected total.
+	if err := l.ReconcileBankDeposit(BankDeposit{Amount: 600, SettlementDate: "2026-02-10"}); err != nil {
+		t.Fatalf("ReconcileBankDeposit (completing): %v", err)
+	}
+	bal = l.GetMerchantBalance("m1")
+	if bal.Available != 1000 {
+		t.Errorf("m1 available after completing deposits: got %d, want 1000", bal.Available)
+	}
+	if bal.Settling != 0 {
+		t.Errorf("m1 settling after completing deposits: got %d, want 0", bal.Settling)
+	}
+}
+
+// TestMultiFileSettlementOfSameTransaction verifies that a transaction
+// settled in fragments across two files accumulates SettledAmount and moves
+// from Pending to PartiallySettled to Settling as rows arrive.
+func TestMultiFileSettlementOfSameTransaction(t *testing.T) {
+	l := NewLedger(nil)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+
+	result1, err := l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 400}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessSettlementFile (first fragment): %v", err)
+	}
+	if result1.PartiallySettled != 1 {
+		t.Errorf("first fragment partially settled: got %d, want 1", result1.PartiallySettled)
+	}
+	if result1.Matched != 0 {
+		t.Errorf("first fragment matched: got %d, want 0", result1.Matched)
+	}
+
+	bal := l.GetMerchantBalance("m1")
+	if bal.Pending != 600 {
+		t.Errorf("m1 pending after first fragment: got %d, want 600", bal.Pending)
+	}
+	if bal.Settling != 400 {
+		t.Errorf("m1 settling after first fragment: got %d, want 400", bal.Settling)
+	}
+
+	result2, err := l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-2",
+		Date:   "2026-02-11",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 600}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessSettlementFile (second fragment): %v", err)
+	}
+	if result2.Matched != 1 {
+		t.Errorf("second fragment matched: got %d, want 1", result2.Matched)
+	}
+
+	bal = l.GetMerchantBalance("m1")
+	if bal.Pending != 0 {
+		t.Errorf("m1 pending after second fragment: got %d, want 0", bal.Pending)
+	}
+	if bal.Settling != 1000 {
+		t.Errorf("m1 settling after second fragment: got %d, want 1000", bal.Settling)
+	}
+}
+
 // TestIdempotentSettlement verifies that processing the same settlement file
 // twice does not create duplicate entries.
 func TestIdempotentSettlement(t *testing.T) {
@@ -188,7 +340,7 @@ func TestIdempotentSettlement(t *testing.T) {
 	file := SettlementFile{
 		FileID: "file-1",
 		Date:   "2026-02-10",
-		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", Amount: 500}},
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 500}},
 	}
 
 	// First processing.
@@ -231,7 +383,7 @@ func TestFailedPayoutRetry(t *testing.T) {
 	_, _ = l.ProcessSettlementFile(SettlementFile{
 		FileID: "file-1",
 		Date:   "2026-02-10",
-		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", Amount: 1000}},
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
 	})
 	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1000, SettlementDate: "2026-02-10"})
 
@@ -267,3 +419,236 @@ func TestFailedPayoutRetry(t *testing.T) {
 		t.Errorf("m1 available after retry: got %d, want 0", bal.Available)
 	}
 }
+
+// TestRecordRefundFromAvailable verifies that refunding a transaction whose
+// funds are still Available reverses them straight back to the card processor.
+func TestRecordRefundFromAvailable(t *testing.T) {
+	l := NewLedger(nil)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1000, SettlementDate: "2026-02-10"})
+
+	if err := l.RecordRefund("txn-1", 1000, "customer requested"); err != nil {
+		t.Fatalf("RecordRefund: %v", err)
+	}
+
+	bal := l.GetMerchantBalance("m1")
+	if bal.Available != 0 {
+		t.Errorf("m1 available after refund: got %d, want 0", bal.Available)
+	}
+	if bal.Clawback != 0 {
+		t.Errorf("m1 clawback after refund from available: got %d, want 0", bal.Clawback)
+	}
+
+	// A settlement row arriving for an already-refunded transaction should be
+	// flagged, not re-settled.
+	result, err := l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-2",
+		Date:   "2026-02-11",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessSettlementFile: %v", err)
+	}
+	if result.Refunds != 1 {
+		t.Errorf("refunds: got %d, want 1", result.Refunds)
+	}
+}
+
+// TestRecordChargebackAfterPayout verifies that charging back money already
+// marked Funded creates a clawback obligation that the next payout batch nets
+// against, rather than underflowing a closed-out account.
+func TestRecordChargebackAfterPayout(t *testing.T) {
+	payoutFunc := func(merchantID string, amount int64, reference string) error {
+		return nil
+	}
+	l := NewLedger(payoutFunc)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1000, SettlementDate: "2026-02-10"})
+	_ = l.ExecutePayoutBatch()
+
+	if err := l.RecordChargeback("txn-1", 1000, "fraud"); err != nil {
+		t.Fatalf("RecordChargeback: %v", err)
+	}
+
+	bal := l.GetMerchantBalance("m1")
+	if bal.Clawback != -1000 {
+		t.Errorf("m1 clawback after chargeback on funded money: got %d, want -1000", bal.Clawback)
+	}
+
+	// A second transaction settles and becomes available for payout; the
+	// clawback should be netted against it.
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-2", MerchantID: "m1", CardNumber: "4242", Amount: 1500, ProcessorRefID: "ref-2",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-2",
+		Date:   "2026-02-11",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-2", MerchantID: "m1", GrossAmount: 1500}},
+	})
+	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1500, SettlementDate: "2026-02-11"})
+
+	results := l.ExecutePayoutBatch()
+	var m1Result *PayoutResult
+	for i := range results {
+		if results[i].MerchantID == "m1" {
+			m1Result = &results[i]
+		}
+	}
+	if m1Result == nil {
+		t.Fatal("expected a payout result for m1")
+	}
+	if m1Result.Amount != 500 {
+		t.Errorf("m1 payout after clawback offset: got %d, want 500", m1Result.Amount)
+	}
+
+	bal = l.GetMerchantBalance("m1")
+	if bal.Clawback != 0 {
+		t.Errorf("m1 clawback after offset: got %d, want 0", bal.Clawback)
+	}
+}
+
+// TestRecordRefundRejectsOverRefund verifies that a refund larger than what a
+// transaction actually has available to reverse is rejected, rather than
+// posting an oversized debit that drives the source account negative.
+func TestRecordRefundRejectsOverRefund(t *testing.T) {
+	l := NewLedger(nil)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+
+	if err := l.RecordRefund("txn-1", 10_000_000, "customer requested"); err == nil {
+		t.Fatal("expected an error refunding far more than the transaction's amount")
+	}
+
+	// The transaction should be untouched: still Pending, for its full amount.
+	bal := l.GetMerchantBalance("m1")
+	if bal.Pending != 1000 {
+		t.Errorf("m1 pending after rejected over-refund: got %d, want 1000", bal.Pending)
+	}
+
+	// A refund of exactly the authorized amount still succeeds.
+	if err := l.RecordRefund("txn-1", 1000, "customer requested"); err != nil {
+		t.Fatalf("RecordRefund: %v", err)
+	}
+}
+
+// TestConcurrentRecordAuthorization verifies that authorizing many
+// transactions concurrently doesn't corrupt shared state or drop entries.
+// Run with -race to catch unguarded map/slice access.
+func TestConcurrentRecordAuthorization(t *testing.T) {
+	l := NewLedger(nil)
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_ = l.RecordAuthorization(Transaction{
+				TransactionID:  fmt.Sprintf("txn-%d", i),
+				MerchantID:     "m1",
+				CardNumber:     "4242",
+				Amount:         100,
+				ProcessorRefID: fmt.Sprintf("ref-%d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	bal := l.GetMerchantBalance("m1")
+	if bal.Pending != n*100 {
+		t.Errorf("m1 pending after concurrent authorizations: got %d, want %d", bal.Pending, n*100)
+	}
+}
+
+// TestConcurrentPayoutBatchNoDoublePay verifies that two ExecutePayoutBatch
+// calls racing each other pay a merchant's available balance out at most
+// once, never twice.
+func TestConcurrentPayoutBatchNoDoublePay(t *testing.T) {
+	var mu sync.Mutex
+	var totalPaid int64
+	payoutFunc := func(merchantID string, amount int64, reference string) error {
+		mu.Lock()
+		totalPaid += amount
+		mu.Unlock()
+		return nil
+	}
+
+	l := NewLedger(payoutFunc)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1000, SettlementDate: "2026-02-10"})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			l.ExecutePayoutBatch()
+		}()
+	}
+	wg.Wait()
+
+	if totalPaid != 1000 {
+		t.Errorf("total paid to m1 across concurrent batches: got %d, want 1000", totalPaid)
+	}
+}
+
+// TestExecutePayoutBatchDoesNotHoldLockDuringPayoutFunc verifies that
+// payoutFunc runs without l.mu held: a payoutFunc that calls back into the
+// ledger (as a real bank integration's callback or status hook plausibly
+// would) must not self-deadlock.
+func TestExecutePayoutBatchDoesNotHoldLockDuringPayoutFunc(t *testing.T) {
+	var l *Ledger
+	payoutFunc := func(merchantID string, amount int64, reference string) error {
+		_ = l.GetMerchantBalance(merchantID) // would deadlock if called under l.mu
+		return nil
+	}
+	l = NewLedger(payoutFunc)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_, _ = l.ProcessSettlementFile(SettlementFile{
+		FileID: "file-1",
+		Date:   "2026-02-10",
+		Rows:   []SettlementRow{{ProcessorRefID: "ref-1", MerchantID: "m1", GrossAmount: 1000}},
+	})
+	_ = l.ReconcileBankDeposit(BankDeposit{Amount: 1000, SettlementDate: "2026-02-10"})
+
+	done := make(chan []PayoutResult, 1)
+	go func() { done <- l.ExecutePayoutBatch() }()
+
+	select {
+	case results := <-done:
+		if len(results) != 1 || !results[0].Success {
+			t.Errorf("expected one successful payout, got %+v", results)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ExecutePayoutBatch deadlocked: payoutFunc must not run under l.mu")
+	}
+}
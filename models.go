@@ -15,16 +15,23 @@ const (
 	AccountAvailable     Account = "available"      // Reconciled with bank deposit
 	AccountFunded        Account = "funded"         // Paid out to merchant
 	AccountMerchantBank  Account = "merchant_bank"  // External: funds in merchants' bank accounts
+	AccountFeeReserve    Account = "fee_reserve"    // Estimated fee held back at authorization time
+	AccountFeeRevenue    Account = "fee_revenue"    // PayFac's recognized fee revenue, posted at settlement
+	AccountClawback      Account = "clawback"       // Negative: owed back by merchant after a refund/chargeback on Funded money
 )
 
 // TransactionStatus tracks where a transaction is in its lifecycle.
 type TransactionStatus string
 
 const (
-	StatusPending   TransactionStatus = "pending"
-	StatusSettling  TransactionStatus = "settling"
-	StatusAvailable TransactionStatus = "available"
-	StatusFunded    TransactionStatus = "funded"
+	StatusPending          TransactionStatus = "pending"
+	StatusPartiallySettled TransactionStatus = "partially_settled" // some, but not all, settlement rows have arrived
+	StatusSettling         TransactionStatus = "settling"
+	StatusAvailable        TransactionStatus = "available"
+	StatusFunding          TransactionStatus = "funding" // claimed by an in-flight ExecutePayoutBatch call, not yet confirmed paid
+	StatusFunded           TransactionStatus = "funded"
+	StatusRefunded         TransactionStatus = "refunded"
+	StatusChargedBack      TransactionStatus = "charged_back"
 )
 
 // Transaction represents a card payment submitted by a merchant.
@@ -34,9 +41,14 @@ type Transaction struct {
 	CardNumber     string // last 4 + token
 	Amount         int64  // in cents
 	ProcessorRefID string // ID assigned by card processor, used to match settlement rows
+	FeeBps         int64  // estimated interchange/processor fee, in basis points of Amount
+	FixedFee       int64  // estimated fixed per-transaction fee, in cents
+	FeeReserved    int64  // fee reserve still outstanding; decremented as settlement rows reverse their share
+	SettledAmount  int64  // cumulative GrossAmount settled so far, across all settlement rows; never exceeds Amount
+	NetSettled     int64  // cumulative net (GrossAmount-FeeAmount) settled so far; this is what moves Settling->Available->Funded
 	Status         TransactionStatus
 	CreatedAt      time.Time
-	SettlementDate Date // set when settled
+	SettlementDate Date // set to the most recent settlement row's file date
 }
 
 // EntryType distinguishes debit from credit entries.
@@ -47,26 +59,54 @@ const (
 	Credit EntryType = "credit"
 )
 
+// EntryPurpose describes why a journal entry was posted, independent of which
+// account it touched. It lets audit and query tooling distinguish, say, a fee
+// reserve from a settlement without parsing the free-text Reference.
+type EntryPurpose string
+
+const (
+	PurposeAuthorization      EntryPurpose = "authorization"
+	PurposeSettlement         EntryPurpose = "settlement"
+	PurposeFeeReserve         EntryPurpose = "fee_reserve"
+	PurposeFeeReserveReversal EntryPurpose = "fee_reserve_reversal"
+	PurposeFeeRevenue         EntryPurpose = "fee_revenue"
+	PurposeBankReconciliation EntryPurpose = "bank_reconciliation"
+	PurposePayout             EntryPurpose = "payout"
+	PurposeRefund             EntryPurpose = "refund"
+	PurposeChargeback         EntryPurpose = "chargeback"
+	PurposeClawbackOffset     EntryPurpose = "clawback_offset"
+)
+
 // LedgerEntry is one row in the double-entry ledger.
 // Every fund movement creates exactly two entries (one debit, one credit)
 // linked by the same JournalID.
 type LedgerEntry struct {
-	ID            int
-	JournalID     int // links the debit and credit halves
-	TransactionID string
-	MerchantID    string
-	Account       Account
-	EntryType     EntryType
-	Amount        int64 // always positive
-	CreatedAt     time.Time
-	Reference     string // human-readable description
+	ID                int
+	JournalID         int // links the debit and credit halves
+	TransactionID     string
+	MerchantID        string
+	Account           Account
+	EntryType         EntryType
+	Purpose           EntryPurpose
+	Amount            int64 // always positive
+	CreatedAt         time.Time
+	Reference         string // human-readable description
+	ReversesJournalID int    // JournalID of the entry this corrects, for refunds/chargebacks; 0 if not a reversal
 }
 
 // SettlementRow is a single row from the processor's daily settlement file.
+// GrossAmount is the amount the row settles; FeeAmount is the actual
+// interchange/processor fee the processor deducted for this row, which may
+// differ from the estimate reserved at authorization time. A ProcessorRefID
+// may appear in more than one row, across one or more files, when the
+// processor settles a transaction in fragments (partial captures) — the
+// GrossAmount across all of a transaction's rows must not exceed its
+// authorized Amount.
 type SettlementRow struct {
 	ProcessorRefID string
 	MerchantID     string
-	Amount         int64
+	GrossAmount    int64
+	FeeAmount      int64
 }
 
 // SettlementFile represents a daily settlement file from the card processor.
@@ -84,18 +124,26 @@ type BankDeposit struct {
 
 // SettlementFileResult summarizes what happened when processing a settlement file.
 type SettlementFileResult struct {
-	Matched        int
-	AlreadySettled int
-	UnmatchedRows  []SettlementRow
+	Matched          int
+	PartiallySettled int // rows that covered part, but not all, of a transaction's Amount
+	AlreadySettled   int
+	Unmatched        int
+	Refunds          int // rows for transactions already refunded before this file arrived
+	Chargebacks      int // rows for transactions already charged back before this file arrived
+	UnmatchedRows    []SettlementRow
 }
 
-// Balance shows how much money is in each account state.
-type Balance struct {
+// MerchantBalance shows how much money is in each account state, net of fees.
+// It is also used for the system-wide balance, in which case MerchantID is empty.
+type MerchantBalance struct {
 	MerchantID string // empty for system-wide balance
 	Pending    int64
 	Settling   int64
 	Available  int64
 	Funded     int64
+	FeeReserve int64 // estimated fees held back pending settlement
+	FeeRevenue int64 // fees actually recognized at settlement
+	Clawback   int64 // negative: owed back by the merchant after refunding/charging back already-Funded money
 }
 
 // PayoutResult reports the outcome of a single merchant payout.
@@ -105,3 +153,77 @@ type PayoutResult struct {
 	Success    bool
 	Error      error
 }
+
+// SortBy identifies the field and direction a query orders its results by.
+type SortBy string
+
+const (
+	SortByCreatedAtAsc  SortBy = "created_at_asc" // default when unset
+	SortByCreatedAtDesc SortBy = "created_at_desc"
+	SortByJournalIDAsc  SortBy = "journal_id_asc"
+	SortByJournalIDDesc SortBy = "journal_id_desc"
+)
+
+// TimeRange bounds a query by CreatedAt, inclusive on both ends. A zero value
+// on either end leaves that side open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// EntryQuery filters and paginates a query over the journal. Zero-valued
+// fields are not applied as filters. Limit defaults to 100 if zero or
+// negative; Cursor, when set, resumes after the entry returned as
+// EntryPage.NextCursor by a previous call.
+type EntryQuery struct {
+	MerchantID    string
+	Account       Account
+	Purpose       EntryPurpose
+	TransactionID string
+	JournalID     int
+	CreatedAt     TimeRange
+	Limit         int
+	Cursor        string
+	SortBy        SortBy
+}
+
+// EntryPage is one page of an EntryQuery. NextCursor is empty once there are
+// no more matching entries to return; it stays valid across later writes,
+// since it identifies a specific entry rather than a page offset.
+type EntryPage struct {
+	Entries    []LedgerEntry
+	NextCursor string
+}
+
+// TxnQuery filters and paginates a query over transactions. Zero-valued
+// fields are not applied as filters. Limit defaults to 100 if zero or
+// negative; Cursor, when set, resumes after the transaction returned as
+// TransactionPage.NextCursor by a previous call.
+type TxnQuery struct {
+	MerchantID     string
+	Status         TransactionStatus
+	SettlementDate Date
+	Limit          int
+	Cursor         string
+}
+
+// TransactionPage is one page of a TxnQuery. NextCursor is empty once there
+// are no more matching transactions to return.
+type TransactionPage struct {
+	Transactions []*Transaction
+	NextCursor   string
+}
+
+// DailyStatement summarizes one merchant's activity for a single calendar
+// date: the balance carried in, every journal entry posted that day (grouped
+// by JournalID, so each posting's debit and credit stay together), how much
+// fee revenue and payout activity occurred, and the balance carried out.
+type DailyStatement struct {
+	MerchantID     string
+	Date           Date
+	OpeningBalance MerchantBalance
+	Journals       [][]LedgerEntry // each inner slice is one JournalID's entries, in append order
+	FeeRevenue     int64
+	Payouts        int64
+	ClosingBalance MerchantBalance
+}
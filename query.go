@@ -0,0 +1,166 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// defaultQueryLimit is the page size used by QueryEntries and
+// QueryTransactions when the caller leaves Limit unset.
+const defaultQueryLimit = 100
+
+// QueryEntries returns a filtered, sorted, paged view over the journal.
+// Entries are keyed by a monotonically increasing ID, so NextCursor stays
+// valid for the next call even as new entries are appended between pages.
+func (l *Ledger) QueryEntries(q EntryQuery) (EntryPage, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var candidates []LedgerEntry
+	if q.MerchantID != "" {
+		candidates = l.store.EntriesByMerchant(q.MerchantID)
+	} else {
+		candidates = l.store.Entries()
+	}
+
+	filtered := make([]LedgerEntry, 0, len(candidates))
+	for _, e := range candidates {
+		if q.Account != "" && e.Account != q.Account {
+			continue
+		}
+		if q.Purpose != "" && e.Purpose != q.Purpose {
+			continue
+		}
+		if q.TransactionID != "" && e.TransactionID != q.TransactionID {
+			continue
+		}
+		if q.JournalID != 0 && e.JournalID != q.JournalID {
+			continue
+		}
+		if !q.CreatedAt.From.IsZero() && e.CreatedAt.Before(q.CreatedAt.From) {
+			continue
+		}
+		if !q.CreatedAt.To.IsZero() && e.CreatedAt.After(q.CreatedAt.To) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	sortEntries(filtered, q.SortBy)
+
+	start := 0
+	if q.Cursor != "" {
+		afterID, err := strconv.Atoi(q.Cursor)
+		if err != nil {
+			return EntryPage{}, fmt.Errorf("invalid cursor %q: %w", q.Cursor, err)
+		}
+		for i, e := range filtered {
+			if e.ID == afterID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	end := start + limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+
+	page := filtered[start:end]
+	var nextCursor string
+	if end < len(filtered) {
+		nextCursor = strconv.Itoa(page[len(page)-1].ID)
+	}
+
+	return EntryPage{Entries: page, NextCursor: nextCursor}, nil
+}
+
+// sortEntries orders entries in place according to sortBy, defaulting to
+// created_at ascending (append order) when sortBy is unset.
+func sortEntries(entries []LedgerEntry, sortBy SortBy) {
+	switch sortBy {
+	case SortByCreatedAtDesc:
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	case SortByJournalIDAsc:
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].JournalID < entries[j].JournalID })
+	case SortByJournalIDDesc:
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].JournalID > entries[j].JournalID })
+	default:
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	}
+}
+
+// QueryTransactions returns a filtered, paged view over transactions, sorted
+// by CreatedAt ascending. NextCursor holds the last returned TransactionID,
+// so the next call resumes from there even as new transactions are recorded.
+func (l *Ledger) QueryTransactions(q TxnQuery) (TransactionPage, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	candidates := l.store.Transactions()
+	filtered := make([]*Transaction, 0, len(candidates))
+	for _, txn := range candidates {
+		if q.MerchantID != "" && txn.MerchantID != q.MerchantID {
+			continue
+		}
+		if q.Status != "" && txn.Status != q.Status {
+			continue
+		}
+		if q.SettlementDate != "" && txn.SettlementDate != q.SettlementDate {
+			continue
+		}
+		filtered = append(filtered, txn)
+	}
+
+	// Transactions come from Store.Transactions(), which (for MemoryStore)
+	// ranges a Go map in nondeterministic order; CreatedAt alone isn't a
+	// reliable sort key since two transactions can share it. TransactionID
+	// breaks ties deterministically so the same query returns rows in the
+	// same order every time, which the cursor depends on to avoid skipping
+	// or repeating rows across pages.
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if !filtered[i].CreatedAt.Equal(filtered[j].CreatedAt) {
+			return filtered[i].CreatedAt.Before(filtered[j].CreatedAt)
+		}
+		return filtered[i].TransactionID < filtered[j].TransactionID
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		for i, txn := range filtered {
+			if txn.TransactionID == q.Cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	end := start + limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+
+	page := filtered[start:end]
+	var nextCursor string
+	if end < len(filtered) {
+		nextCursor = page[len(page)-1].TransactionID
+	}
+
+	return TransactionPage{Transactions: page, NextCursor: nextCursor}, nil
+}
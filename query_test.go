@@ -0,0 +1,155 @@
+package ledger
+
+import "testing"
+
+// sameCreatedAt forces every one of the given transactions to share exactly
+// the same CreatedAt, simulating the real-world case of several transactions
+// authorized in the same instant (or a store whose clock resolution is
+// coarser than the request rate). Requires a MemoryStore-backed Ledger.
+func sameCreatedAt(t *testing.T, l *Ledger, txnIDs []string) {
+	t.Helper()
+	ms, ok := l.store.(*MemoryStore)
+	if !ok {
+		t.Fatalf("sameCreatedAt requires a MemoryStore-backed Ledger")
+	}
+	at := ms.transactions[txnIDs[0]].CreatedAt
+	for _, id := range txnIDs {
+		ms.transactions[id].CreatedAt = at
+	}
+}
+
+// TestQueryEntriesFiltersAndPaginates verifies that QueryEntries filters by
+// merchant and purpose, and that paging through small pages with NextCursor
+// returns every matching entry exactly once.
+func TestQueryEntriesFiltersAndPaginates(t *testing.T) {
+	l := NewLedger(nil)
+
+	txnRefs := map[string]string{"txn-1": "ref-1", "txn-2": "ref-2", "txn-3": "ref-3"}
+	for _, txnID := range []string{"txn-1", "txn-2", "txn-3"} {
+		_ = l.RecordAuthorization(Transaction{
+			TransactionID: txnID, MerchantID: "m1", CardNumber: "4242",
+			Amount: 1000, ProcessorRefID: txnRefs[txnID],
+		})
+	}
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-other", MerchantID: "m2", CardNumber: "4242", Amount: 500, ProcessorRefID: "ref-other",
+	})
+
+	// Only authorization entries for m1: one debit + one credit per txn, 3 txns = 6 entries.
+	page, err := l.QueryEntries(EntryQuery{MerchantID: "m1", Purpose: PurposeAuthorization, Limit: 4})
+	if err != nil {
+		t.Fatalf("QueryEntries: %v", err)
+	}
+	if len(page.Entries) != 4 {
+		t.Fatalf("first page: got %d entries, want 4", len(page.Entries))
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a NextCursor since more entries remain")
+	}
+
+	page2, err := l.QueryEntries(EntryQuery{MerchantID: "m1", Purpose: PurposeAuthorization, Limit: 4, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("QueryEntries (page 2): %v", err)
+	}
+	if len(page2.Entries) != 2 {
+		t.Fatalf("second page: got %d entries, want 2", len(page2.Entries))
+	}
+	if page2.NextCursor != "" {
+		t.Errorf("expected no NextCursor once all entries are returned, got %q", page2.NextCursor)
+	}
+
+	for _, e := range append(page.Entries, page2.Entries...) {
+		if e.MerchantID != "m1" {
+			t.Errorf("entry for wrong merchant leaked into query: %+v", e)
+		}
+	}
+}
+
+// TestQueryTransactionsStableOrderWithTiedCreatedAt verifies that
+// QueryTransactions still returns a deterministic, repeatable order — and
+// therefore never skips or repeats a row across pages — when multiple
+// transactions share the exact same CreatedAt, which CreatedAt alone cannot
+// break a tie on.
+func TestQueryTransactionsStableOrderWithTiedCreatedAt(t *testing.T) {
+	l := NewLedger(nil)
+
+	txnIDs := []string{"txn-3", "txn-1", "txn-4", "txn-2"}
+	for _, txnID := range txnIDs {
+		_ = l.RecordAuthorization(Transaction{
+			TransactionID: txnID, MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-" + txnID,
+		})
+	}
+	sameCreatedAt(t, l, txnIDs)
+
+	first, err := l.QueryTransactions(TxnQuery{MerchantID: "m1"})
+	if err != nil {
+		t.Fatalf("QueryTransactions: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		again, err := l.QueryTransactions(TxnQuery{MerchantID: "m1"})
+		if err != nil {
+			t.Fatalf("QueryTransactions (repeat %d): %v", i, err)
+		}
+		if len(again.Transactions) != len(first.Transactions) {
+			t.Fatalf("repeat %d: got %d transactions, want %d", i, len(again.Transactions), len(first.Transactions))
+		}
+		for j, txn := range again.Transactions {
+			if txn.TransactionID != first.Transactions[j].TransactionID {
+				t.Fatalf("repeat %d: order changed at index %d: got %s, want %s", i, j, txn.TransactionID, first.Transactions[j].TransactionID)
+			}
+		}
+	}
+
+	// Page through two at a time and confirm every transaction is returned
+	// exactly once, in the same order as the unpaged query.
+	var paged []*Transaction
+	cursor := ""
+	for {
+		page, err := l.QueryTransactions(TxnQuery{MerchantID: "m1", Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("QueryTransactions (paged): %v", err)
+		}
+		paged = append(paged, page.Transactions...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(paged) != len(first.Transactions) {
+		t.Fatalf("paged: got %d transactions, want %d", len(paged), len(first.Transactions))
+	}
+	for i, txn := range paged {
+		if txn.TransactionID != first.Transactions[i].TransactionID {
+			t.Errorf("paged order differs from unpaged at index %d: got %s, want %s", i, txn.TransactionID, first.Transactions[i].TransactionID)
+		}
+	}
+}
+
+// TestQueryTransactionsFilters verifies that QueryTransactions filters by
+// merchant and status.
+func TestQueryTransactionsFilters(t *testing.T) {
+	l := NewLedger(nil)
+
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-1", MerchantID: "m1", CardNumber: "4242", Amount: 1000, ProcessorRefID: "ref-1",
+	})
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-2", MerchantID: "m1", CardNumber: "4242", Amount: 2000, ProcessorRefID: "ref-2",
+	})
+	_ = l.RecordAuthorization(Transaction{
+		TransactionID: "txn-3", MerchantID: "m2", CardNumber: "4242", Amount: 3000, ProcessorRefID: "ref-3",
+	})
+
+	page, err := l.QueryTransactions(TxnQuery{MerchantID: "m1", Status: StatusPending})
+	if err != nil {
+		t.Fatalf("QueryTransactions: %v", err)
+	}
+	if len(page.Transactions) != 2 {
+		t.Fatalf("m1 pending transactions: got %d, want 2", len(page.Transactions))
+	}
+	for _, txn := range page.Transactions {
+		if txn.MerchantID != "m1" {
+			t.Errorf("transaction for wrong merchant leaked into query: %+v", txn)
+		}
+	}
+}
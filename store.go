@@ -0,0 +1,204 @@
+package ledger
+
+import (
+	"errors"
+	"iter"
+)
+
+// ErrNotFound is returned by Store lookups that find nothing.
+var ErrNotFound = errors.New("ledger: not found")
+
+// Store persists everything a Ledger needs: transactions, the journal, and
+// the bookkeeping around settlement files and totals. It exists so a Ledger
+// can run across process restarts, and — for implementations that enforce
+// idempotency at the storage layer — across multiple processes without
+// double-posting the same entries.
+type Store interface {
+	// SaveTransaction creates or updates a transaction record. Callers persist
+	// a transaction again after mutating its Status or other fields.
+	SaveTransaction(txn *Transaction) error
+
+	// GetTransaction returns the transaction with the given ID, or
+	// ErrNotFound if it doesn't exist.
+	GetTransaction(id string) (*Transaction, error)
+
+	// Transactions returns every transaction in the store. Used by the
+	// payout scans and QueryTransactions, which need to consider all of them.
+	Transactions() []*Transaction
+
+	// LookupByProcessorRef resolves a processor reference ID to the
+	// transaction ID it was recorded against, if any.
+	LookupByProcessorRef(ref string) (string, bool)
+
+	// IterateByStatusAndDate lazily yields transactions matching a status and
+	// settlement date, for bulk operations like ReconcileBankDeposit that
+	// would otherwise need to scan and filter every transaction in the store.
+	IterateByStatusAndDate(status TransactionStatus, date Date) iter.Seq[*Transaction]
+
+	// AppendJournal durably persists a batch of entries that make up one
+	// journal posting. Each entry already carries the JournalID the caller
+	// reserved via NextJournalID. In PostgresStore a retried or
+	// concurrently-duplicated posting of the same batch collides on
+	// (journal_id, account, entry_type) and is silently skipped rather than
+	// double-applied; MemoryStore does no such check and appends every batch
+	// it is given.
+	// Implementations must not partially apply a batch: if some, but not
+	// all, of its entries collide, that's a genuine inconsistency and
+	// AppendJournal must return an error instead of posting half a journal
+	// entry.
+	AppendJournal(entries []LedgerEntry) error
+
+	// Entries returns every journal entry recorded so far, in the order they
+	// were appended. Used by the balance and query methods that fold over
+	// the full journal.
+	Entries() []LedgerEntry
+
+	// EntriesByMerchant returns one merchant's journal entries, in the order
+	// they were appended, without scanning every other merchant's entries.
+	// Used by merchant-scoped balance and query methods so they stay cheap
+	// as the journal grows.
+	EntriesByMerchant(merchantID string) []LedgerEntry
+
+	// NextJournalID returns a fresh, store-unique journal ID for the next
+	// posting, so IDs stay collision-free across restarts and processes.
+	NextJournalID() int
+
+	// MarkFileProcessed records that a settlement file has been processed.
+	// It returns false if the file was already marked, so callers can treat
+	// reprocessing as a no-op rather than an error.
+	MarkFileProcessed(fileID string) (bool, error)
+
+	// SettlementTotal returns the net total expected for a settlement date,
+	// accumulated across every settlement file recorded for that date.
+	SettlementTotal(date Date) (int64, bool)
+
+	// AddSettlementTotal adds to the net total expected for a settlement
+	// date. Processors split a day across multiple files, so each file's net
+	// total accumulates rather than replaces the date's running total.
+	AddSettlementTotal(date Date, amount int64)
+
+	// SettlementReceived returns the cumulative amount received via
+	// ReconcileBankDeposit for a settlement date so far.
+	SettlementReceived(date Date) (int64, bool)
+
+	// AddSettlementReceived adds to the cumulative amount received for a
+	// settlement date, so deposits that only partially cover a date's
+	// expected total can be tracked until a later deposit completes it.
+	AddSettlementReceived(date Date, amount int64)
+}
+
+// MemoryStore is the default Store: everything lives in process memory and
+// is lost on restart. It's what Ledger used before storage was pluggable,
+// and remains the right choice for tests and single-process deployments.
+type MemoryStore struct {
+	transactions       map[string]*Transaction // keyed by transaction_id
+	refIndex           map[string]string       // processor_ref_id -> transaction_id
+	entries            []LedgerEntry
+	merchantEntries    map[string][]LedgerEntry // merchant_id -> that merchant's entries, in append order
+	nextJournalID      int
+	processedFiles     map[string]bool // settlement file IDs already processed
+	settlementExpected map[Date]int64  // settlement_date -> expected net total, across all files
+	settlementReceived map[Date]int64  // settlement_date -> cumulative deposits received so far
+}
+
+// NewMemoryStore creates an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		transactions:       make(map[string]*Transaction),
+		refIndex:           make(map[string]string),
+		merchantEntries:    make(map[string][]LedgerEntry),
+		processedFiles:     make(map[string]bool),
+		settlementExpected: make(map[Date]int64),
+		settlementReceived: make(map[Date]int64),
+	}
+}
+
+func (s *MemoryStore) SaveTransaction(txn *Transaction) error {
+	s.transactions[txn.TransactionID] = txn
+	if txn.ProcessorRefID != "" {
+		s.refIndex[txn.ProcessorRefID] = txn.TransactionID
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetTransaction(id string) (*Transaction, error) {
+	txn, ok := s.transactions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return txn, nil
+}
+
+func (s *MemoryStore) Transactions() []*Transaction {
+	txns := make([]*Transaction, 0, len(s.transactions))
+	for _, txn := range s.transactions {
+		txns = append(txns, txn)
+	}
+	return txns
+}
+
+func (s *MemoryStore) LookupByProcessorRef(ref string) (string, bool) {
+	txnID, ok := s.refIndex[ref]
+	return txnID, ok
+}
+
+func (s *MemoryStore) IterateByStatusAndDate(status TransactionStatus, date Date) iter.Seq[*Transaction] {
+	return func(yield func(*Transaction) bool) {
+		for _, txn := range s.transactions {
+			if txn.Status == status && txn.SettlementDate == date {
+				if !yield(txn) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *MemoryStore) AppendJournal(entries []LedgerEntry) error {
+	s.entries = append(s.entries, entries...)
+	for _, e := range entries {
+		if e.MerchantID != "" {
+			s.merchantEntries[e.MerchantID] = append(s.merchantEntries[e.MerchantID], e)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Entries() []LedgerEntry {
+	return s.entries
+}
+
+func (s *MemoryStore) EntriesByMerchant(merchantID string) []LedgerEntry {
+	return s.merchantEntries[merchantID]
+}
+
+func (s *MemoryStore) NextJournalID() int {
+	s.nextJournalID++
+	return s.nextJournalID
+}
+
+func (s *MemoryStore) MarkFileProcessed(fileID string) (bool, error) {
+	if s.processedFiles[fileID] {
+		return false, nil
+	}
+	s.processedFiles[fileID] = true
+	return true, nil
+}
+
+func (s *MemoryStore) SettlementTotal(date Date) (int64, bool) {
+	total, ok := s.settlementExpected[date]
+	return total, ok
+}
+
+func (s *MemoryStore) AddSettlementTotal(date Date, amount int64) {
+	s.settlementExpected[date] += amount
+}
+
+func (s *MemoryStore) SettlementReceived(date Date) (int64, bool) {
+	received, ok := s.settlementReceived[date]
+	return received, ok
+}
+
+func (s *MemoryStore) AddSettlementReceived(date Date, amount int64) {
+	s.settlementReceived[date] += amount
+}
@@ -0,0 +1,328 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// postgresTransaction is the bun model backing Transaction in Postgres.
+type postgresTransaction struct {
+	bun.BaseModel `bun:"table:ledger_transactions"`
+
+	TransactionID  string `bun:",pk"`
+	MerchantID     string
+	CardNumber     string
+	Amount         int64
+	ProcessorRefID string `bun:",unique"`
+	FeeBps         int64
+	FixedFee       int64
+	FeeReserved    int64
+	SettledAmount  int64
+	NetSettled     int64
+	Status         TransactionStatus
+	CreatedAt      time.Time
+	SettlementDate Date
+}
+
+// postgresEntry is the bun model backing LedgerEntry in Postgres. The unique
+// index on (journal_id, account, entry_type) is what makes AppendJournal
+// idempotent at the database layer: a retried or concurrently-duplicated
+// posting of the same journal ID collides on insert instead of double
+// crediting an account. It's deliberately not keyed on
+// (transaction_id, account, entry_purpose): partial settlement (see
+// ProcessSettlementFile) legitimately posts more than one
+// Pending->Settling/purpose=settlement entry for the same transaction, across
+// separate journal IDs, and a constraint on that wider tuple would reject the
+// second one.
+type postgresEntry struct {
+	bun.BaseModel `bun:"table:ledger_entries"`
+
+	ID                int `bun:",pk,autoincrement"`
+	JournalID         int `bun:",unique:ledger_entries_journal_account_type"`
+	TransactionID     string
+	MerchantID        string
+	Account           Account   `bun:",unique:ledger_entries_journal_account_type"`
+	EntryType         EntryType `bun:",unique:ledger_entries_journal_account_type"`
+	Purpose           EntryPurpose
+	Amount            int64
+	CreatedAt         time.Time
+	Reference         string
+	ReversesJournalID int
+}
+
+// postgresSettlementTotal backs the settlement_totals table that
+// ReconcileBankDeposit checks deposits against. Expected accumulates across
+// every settlement file recorded for the date; Received accumulates across
+// every deposit reconciled against it, since processors split both sides
+// across multiple files and multiple deposits for the same date.
+type postgresSettlementTotal struct {
+	bun.BaseModel `bun:"table:ledger_settlement_totals"`
+
+	SettlementDate Date `bun:",pk"`
+	Expected       int64
+	Received       int64
+}
+
+// postgresProcessedFile backs the idempotency check in ProcessSettlementFile.
+type postgresProcessedFile struct {
+	bun.BaseModel `bun:"table:ledger_processed_files"`
+
+	FileID string `bun:",pk"`
+}
+
+// PostgresStore is a Store backed by Postgres via bun. It's the Store to use
+// for running the ledger across restarts and multiple processes: unlike
+// MemoryStore, a unique constraint on (journal_id, account, entry_type)
+// enforces journal idempotency in the database itself, so two processes
+// racing to post the same journal entry can't both succeed.
+type PostgresStore struct {
+	db *bun.DB
+}
+
+// NewPostgresStore wraps an already-connected bun.DB. Callers are expected to
+// have run the migrations that create the ledger_* tables and the
+// ledger_entries_journal_account_type constraint before use.
+func NewPostgresStore(db *bun.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) SaveTransaction(txn *Transaction) error {
+	row := fromTransaction(txn)
+	_, err := s.db.NewInsert().
+		Model(&row).
+		On("CONFLICT (transaction_id) DO UPDATE").
+		Exec(context.Background())
+	return err
+}
+
+func (s *PostgresStore) GetTransaction(id string) (*Transaction, error) {
+	var row postgresTransaction
+	err := s.db.NewSelect().Model(&row).Where("transaction_id = ?", id).Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toTransaction(), nil
+}
+
+func (s *PostgresStore) Transactions() []*Transaction {
+	var rows []postgresTransaction
+	if err := s.db.NewSelect().Model(&rows).Scan(context.Background()); err != nil {
+		return nil
+	}
+	txns := make([]*Transaction, 0, len(rows))
+	for i := range rows {
+		txns = append(txns, rows[i].toTransaction())
+	}
+	return txns
+}
+
+func (s *PostgresStore) LookupByProcessorRef(ref string) (string, bool) {
+	var row postgresTransaction
+	err := s.db.NewSelect().Model(&row).Where("processor_ref_id = ?", ref).Scan(context.Background())
+	if err != nil {
+		return "", false
+	}
+	return row.TransactionID, true
+}
+
+func (s *PostgresStore) IterateByStatusAndDate(status TransactionStatus, date Date) iter.Seq[*Transaction] {
+	return func(yield func(*Transaction) bool) {
+		var rows []postgresTransaction
+		err := s.db.NewSelect().Model(&rows).
+			Where("status = ? AND settlement_date = ?", status, date).
+			Scan(context.Background())
+		if err != nil {
+			return
+		}
+		for i := range rows {
+			if !yield(rows[i].toTransaction()) {
+				return
+			}
+		}
+	}
+}
+
+func (s *PostgresStore) AppendJournal(entries []LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	rows := make([]postgresEntry, len(entries))
+	for i, e := range entries {
+		rows[i] = fromEntry(e)
+	}
+	res, err := s.db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (journal_id, account, entry_type) DO NOTHING").
+		Exec(context.Background())
+	if err != nil {
+		return fmt.Errorf("appending journal: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("appending journal: %w", err)
+	}
+	// A fresh posting inserts every row; a retried, already-posted batch
+	// collides on all of them and inserts none. Anything in between means
+	// only some of the batch's rows already existed, which would leave a
+	// journal entry with only its debit or only its credit half posted —
+	// a genuine inconsistency, not an idempotent retry.
+	if affected != 0 && int(affected) != len(entries) {
+		return fmt.Errorf("appending journal: %d of %d entries collided with an existing posting", len(entries)-int(affected), len(entries))
+	}
+	return nil
+}
+
+func (s *PostgresStore) Entries() []LedgerEntry {
+	var rows []postgresEntry
+	if err := s.db.NewSelect().Model(&rows).Order("id ASC").Scan(context.Background()); err != nil {
+		return nil
+	}
+	entries := make([]LedgerEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.toEntry())
+	}
+	return entries
+}
+
+func (s *PostgresStore) EntriesByMerchant(merchantID string) []LedgerEntry {
+	var rows []postgresEntry
+	err := s.db.NewSelect().Model(&rows).Where("merchant_id = ?", merchantID).Order("id ASC").Scan(context.Background())
+	if err != nil {
+		return nil
+	}
+	entries := make([]LedgerEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.toEntry())
+	}
+	return entries
+}
+
+func (s *PostgresStore) NextJournalID() int {
+	var id int
+	_ = s.db.QueryRow("SELECT nextval('ledger_journal_id_seq')").Scan(&id)
+	return id
+}
+
+func (s *PostgresStore) MarkFileProcessed(fileID string) (bool, error) {
+	row := postgresProcessedFile{FileID: fileID}
+	res, err := s.db.NewInsert().Model(&row).On("CONFLICT DO NOTHING").Exec(context.Background())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *PostgresStore) SettlementTotal(date Date) (int64, bool) {
+	var row postgresSettlementTotal
+	err := s.db.NewSelect().Model(&row).Where("settlement_date = ?", date).Scan(context.Background())
+	if err != nil {
+		return 0, false
+	}
+	return row.Expected, true
+}
+
+func (s *PostgresStore) AddSettlementTotal(date Date, amount int64) {
+	row := postgresSettlementTotal{SettlementDate: date, Expected: amount}
+	_, _ = s.db.NewInsert().
+		Model(&row).
+		On("CONFLICT (settlement_date) DO UPDATE SET expected = ledger_settlement_totals.expected + EXCLUDED.expected").
+		Exec(context.Background())
+}
+
+func (s *PostgresStore) SettlementReceived(date Date) (int64, bool) {
+	var row postgresSettlementTotal
+	err := s.db.NewSelect().Model(&row).Where("settlement_date = ?", date).Scan(context.Background())
+	if err != nil {
+		return 0, false
+	}
+	return row.Received, true
+}
+
+func (s *PostgresStore) AddSettlementReceived(date Date, amount int64) {
+	row := postgresSettlementTotal{SettlementDate: date, Received: amount}
+	_, _ = s.db.NewInsert().
+		Model(&row).
+		On("CONFLICT (settlement_date) DO UPDATE SET received = ledger_settlement_totals.received + EXCLUDED.received").
+		Exec(context.Background())
+}
+
+func fromTransaction(txn *Transaction) postgresTransaction {
+	return postgresTransaction{
+		TransactionID:  txn.TransactionID,
+		MerchantID:     txn.MerchantID,
+		CardNumber:     txn.CardNumber,
+		Amount:         txn.Amount,
+		ProcessorRefID: txn.ProcessorRefID,
+		FeeBps:         txn.FeeBps,
+		FixedFee:       txn.FixedFee,
+		FeeReserved:    txn.FeeReserved,
+		SettledAmount:  txn.SettledAmount,
+		NetSettled:     txn.NetSettled,
+		Status:         txn.Status,
+		CreatedAt:      txn.CreatedAt,
+		SettlementDate: txn.SettlementDate,
+	}
+}
+
+func (row *postgresTransaction) toTransaction() *Transaction {
+	return &Transaction{
+		TransactionID:  row.TransactionID,
+		MerchantID:     row.MerchantID,
+		CardNumber:     row.CardNumber,
+		Amount:         row.Amount,
+		ProcessorRefID: row.ProcessorRefID,
+		FeeBps:         row.FeeBps,
+		FixedFee:       row.FixedFee,
+		FeeReserved:    row.FeeReserved,
+		SettledAmount:  row.SettledAmount,
+		NetSettled:     row.NetSettled,
+		Status:         row.Status,
+		CreatedAt:      row.CreatedAt,
+		SettlementDate: row.SettlementDate,
+	}
+}
+
+func fromEntry(e LedgerEntry) postgresEntry {
+	return postgresEntry{
+		ID:                e.ID,
+		JournalID:         e.JournalID,
+		TransactionID:     e.TransactionID,
+		MerchantID:        e.MerchantID,
+		Account:           e.Account,
+		EntryType:         e.EntryType,
+		Purpose:           e.Purpose,
+		Amount:            e.Amount,
+		CreatedAt:         e.CreatedAt,
+		Reference:         e.Reference,
+		ReversesJournalID: e.ReversesJournalID,
+	}
+}
+
+func (row postgresEntry) toEntry() LedgerEntry {
+	return LedgerEntry{
+		ID:                row.ID,
+		JournalID:         row.JournalID,
+		TransactionID:     row.TransactionID,
+		MerchantID:        row.MerchantID,
+		Account:           row.Account,
+		EntryType:         row.EntryType,
+		Purpose:           row.Purpose,
+		Amount:            row.Amount,
+		CreatedAt:         row.CreatedAt,
+		Reference:         row.Reference,
+		ReversesJournalID: row.ReversesJournalID,
+	}
+}